@@ -0,0 +1,332 @@
+// AST node definitions for (a subset of) the AWK language.
+//
+// This file holds the concrete types that walk.go's Node/Visitor
+// machinery dispatches over: Program and Function at the top, then
+// every Expr and Stmt implementation. Keeping them in one file makes
+// it easy to see the whole shape of the tree at a glance.
+
+package ast
+
+import "github.com/benhoyt/goawk/lexer"
+
+// VarScope says where a variable lives: as a global, as a parameter or
+// local in the current function, or as one of AWK's special variables
+// (NF, NR, and so on).
+type VarScope int
+
+const (
+	ScopeGlobal VarScope = iota
+	ScopeLocal
+	ScopeSpecial
+)
+
+// specialVars maps AWK's built-in variable names to their fixed
+// indexes, used by the interpreter to give them dedicated storage
+// instead of going through the scalar/array maps like user variables.
+var specialVars = map[string]int{
+	"NF":       1,
+	"NR":       2,
+	"FS":       3,
+	"OFS":      4,
+	"RS":       5,
+	"ORS":      6,
+	"FILENAME": 7,
+	"SUBSEP":   8,
+	"RSTART":   9,
+	"RLENGTH":  10,
+}
+
+// SpecialVarIndex returns the fixed index of an AWK special variable
+// such as NF or FS, or 0 if name isn't one.
+func SpecialVarIndex(name string) int {
+	return specialVars[name]
+}
+
+// Expr is implemented by every expression node.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Stmt is implemented by every statement node.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Stmts is a sequence of statements, e.g. a function or block body.
+type Stmts []Stmt
+
+// Program is a whole parsed (but not yet resolved) AWK program.
+type Program struct {
+	Begin     Stmts
+	Actions   []Action
+	End       Stmts
+	Functions []Function
+	Scalars   map[string]int
+	Arrays    map[string]int
+}
+
+// Action is a single pattern-action rule, e.g. "$1 == "x" { print }".
+// Pattern is empty for an unconditional (bare "{ ... }") rule.
+type Action struct {
+	Pattern []Expr
+	Stmts   Stmts
+}
+
+// Function is a user function declaration. IsArray marks which
+// parameters were given the explicit "name[]" array annotation,
+// parallel to Params (nil if none were annotated). Arrays is filled in
+// later by the resolver and tells the interpreter which parameter
+// slots actually hold arrays.
+type Function struct {
+	Name    string
+	Params  []string
+	IsArray []bool
+	Arrays  []bool
+	Body    Stmts
+}
+
+// --- Expressions ---
+
+// FieldExpr is a field reference, e.g. $1 or $NF.
+type FieldExpr struct {
+	Index Expr
+}
+
+// VarExpr is a scalar variable reference. Scope and Index are filled
+// in by the resolver; Pos is the position of this exact reference, set
+// when the parser builds the node.
+type VarExpr struct {
+	Scope VarScope
+	Index int
+	Name  string
+	Pos   lexer.Position
+}
+
+// ArrayExpr is an array variable reference (used bare, e.g. as a call
+// argument passing the array by reference, or as the Array of an
+// IndexExpr/DeleteStmt/InExpr). Scope and Index are filled in by the
+// resolver; Pos is the position of this exact reference.
+type ArrayExpr struct {
+	Scope VarScope
+	Index int
+	Name  string
+	Pos   lexer.Position
+}
+
+// RegExpr is a standalone "/regex/" literal, matched against $0.
+type RegExpr struct {
+	Regex string
+}
+
+// NumExpr is a numeric literal.
+type NumExpr struct {
+	Value float64
+}
+
+// StrExpr is a string literal.
+type StrExpr struct {
+	Value string
+}
+
+// BinaryExpr is a binary operator expression, e.g. Left + Right.
+type BinaryExpr struct {
+	Left  Expr
+	Op    string
+	Right Expr
+}
+
+// UnaryExpr is a unary operator expression, e.g. -Value or !Value.
+type UnaryExpr struct {
+	Op    string
+	Value Expr
+}
+
+// IncrExpr is a pre/post increment or decrement, e.g. Expr++.
+type IncrExpr struct {
+	Expr Expr
+	Op   string
+	Pre  bool
+}
+
+// AssignExpr is a plain assignment, Left = Right.
+type AssignExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+// AugAssignExpr is a compound assignment, e.g. Left += Right.
+type AugAssignExpr struct {
+	Left  Expr
+	Op    string
+	Right Expr
+}
+
+// CondExpr is the ternary conditional, Cond ? True : False.
+type CondExpr struct {
+	Cond  Expr
+	True  Expr
+	False Expr
+}
+
+// GroupingExpr is a parenthesized expression list, e.g. (a, b) in arr.
+type GroupingExpr struct {
+	Exprs []Expr
+}
+
+// InExpr is the "(index) in array" membership test.
+type InExpr struct {
+	Index []Expr
+	Array Expr
+}
+
+// IndexExpr is an array index reference, e.g. arr[i].
+type IndexExpr struct {
+	Array Expr
+	Index []Expr
+}
+
+// CallExpr is a call to a builtin function, e.g. length(s).
+type CallExpr struct {
+	Func string
+	Args []Expr
+}
+
+// UserCallExpr is a call to a user-defined function. Index is filled
+// in by the resolver once the callee is known; Pos is the position of
+// this call expression, set when the parser builds the node.
+type UserCallExpr struct {
+	Name  string
+	Args  []Expr
+	Index int
+	Pos   lexer.Position
+}
+
+// GetlineExpr is a "getline" expression, in any of its forms (Command
+// and File are nil unless "getline < file" or "command | getline").
+type GetlineExpr struct {
+	Target  Expr
+	Command Expr
+	File    Expr
+}
+
+func (*FieldExpr) exprNode()     {}
+func (*VarExpr) exprNode()       {}
+func (*ArrayExpr) exprNode()     {}
+func (*RegExpr) exprNode()       {}
+func (*NumExpr) exprNode()       {}
+func (*StrExpr) exprNode()       {}
+func (*BinaryExpr) exprNode()    {}
+func (*UnaryExpr) exprNode()     {}
+func (*IncrExpr) exprNode()      {}
+func (*AssignExpr) exprNode()    {}
+func (*AugAssignExpr) exprNode() {}
+func (*CondExpr) exprNode()      {}
+func (*GroupingExpr) exprNode()  {}
+func (*InExpr) exprNode()        {}
+func (*IndexExpr) exprNode()     {}
+func (*CallExpr) exprNode()      {}
+func (*UserCallExpr) exprNode()  {}
+func (*GetlineExpr) exprNode()   {}
+
+// --- Statements ---
+
+// ExprStmt is a bare expression used as a statement, e.g. a call.
+type ExprStmt struct {
+	Expr Expr
+}
+
+// PrintStmt is a "print" statement.
+type PrintStmt struct {
+	Args []Expr
+}
+
+// PrintfStmt is a "printf" statement.
+type PrintfStmt struct {
+	Args []Expr
+}
+
+// IfStmt is an if/else statement (Else is empty if there's no else).
+type IfStmt struct {
+	Cond Expr
+	Body Stmts
+	Else Stmts
+}
+
+// ForStmt is a C-style for loop (Pre and Post may be nil).
+type ForStmt struct {
+	Pre  Stmt
+	Cond Expr
+	Post Stmt
+	Body Stmts
+}
+
+// ForInStmt is a "for (k in arr)" loop.
+type ForInStmt struct {
+	Var   Expr
+	Array Expr
+	Body  Stmts
+}
+
+// WhileStmt is a while loop.
+type WhileStmt struct {
+	Cond Expr
+	Body Stmts
+}
+
+// DoWhileStmt is a do/while loop.
+type DoWhileStmt struct {
+	Body Stmts
+	Cond Expr
+}
+
+// BlockStmt is a brace-delimited statement block.
+type BlockStmt struct {
+	Body Stmts
+}
+
+// BreakStmt is a "break" statement.
+type BreakStmt struct{}
+
+// ContinueStmt is a "continue" statement.
+type ContinueStmt struct{}
+
+// NextStmt is a "next" statement.
+type NextStmt struct{}
+
+// NextfileStmt is a "nextfile" statement.
+type NextfileStmt struct{}
+
+// ExitStmt is an "exit" statement (Status may be nil).
+type ExitStmt struct {
+	Status Expr
+}
+
+// DeleteStmt is a "delete arr[index]" (or "delete arr") statement.
+type DeleteStmt struct {
+	Array Expr
+	Index []Expr
+}
+
+// ReturnStmt is a "return" statement (Value may be nil).
+type ReturnStmt struct {
+	Value Expr
+}
+
+func (*ExprStmt) stmtNode()     {}
+func (*PrintStmt) stmtNode()    {}
+func (*PrintfStmt) stmtNode()   {}
+func (*IfStmt) stmtNode()       {}
+func (*ForStmt) stmtNode()      {}
+func (*ForInStmt) stmtNode()    {}
+func (*WhileStmt) stmtNode()    {}
+func (*DoWhileStmt) stmtNode()  {}
+func (*BlockStmt) stmtNode()    {}
+func (*BreakStmt) stmtNode()    {}
+func (*ContinueStmt) stmtNode() {}
+func (*NextStmt) stmtNode()     {}
+func (*NextfileStmt) stmtNode() {}
+func (*ExitStmt) stmtNode()     {}
+func (*DeleteStmt) stmtNode()   {}
+func (*ReturnStmt) stmtNode()   {}
@@ -0,0 +1,199 @@
+// AST walking, modeled on go/ast's Inspect/Walk.
+
+package ast
+
+// Node is implemented by every AST node -- expressions, statements, and
+// the Program/Function nodes that hold them -- so that generic tools
+// (the resolver, but also linters, formatters, and LSP servers) can
+// walk a whole program without switching on every concrete type
+// themselves.
+type Node interface {
+	// unexported so only types in this package can implement Node
+	astNode()
+}
+
+func (*Program) astNode()  {}
+func (*Function) astNode() {}
+
+func (*FieldExpr) astNode()    {}
+func (*VarExpr) astNode()      {}
+func (*ArrayExpr) astNode()    {}
+func (*RegExpr) astNode()      {}
+func (*NumExpr) astNode()      {}
+func (*StrExpr) astNode()      {}
+func (*BinaryExpr) astNode()   {}
+func (*UnaryExpr) astNode()    {}
+func (*IncrExpr) astNode()     {}
+func (*AssignExpr) astNode()   {}
+func (*AugAssignExpr) astNode() {}
+func (*CondExpr) astNode()     {}
+func (*GroupingExpr) astNode() {}
+func (*InExpr) astNode()       {}
+func (*IndexExpr) astNode()    {}
+func (*CallExpr) astNode()     {}
+func (*UserCallExpr) astNode() {}
+func (*GetlineExpr) astNode()  {}
+
+func (*ExprStmt) astNode()     {}
+func (*PrintStmt) astNode()    {}
+func (*PrintfStmt) astNode()   {}
+func (*IfStmt) astNode()       {}
+func (*ForStmt) astNode()      {}
+func (*ForInStmt) astNode()    {}
+func (*WhileStmt) astNode()    {}
+func (*DoWhileStmt) astNode()  {}
+func (*BlockStmt) astNode()    {}
+func (*BreakStmt) astNode()    {}
+func (*ContinueStmt) astNode() {}
+func (*NextStmt) astNode()     {}
+func (*NextfileStmt) astNode() {}
+func (*ExitStmt) astNode()     {}
+func (*DeleteStmt) astNode()   {}
+func (*ReturnStmt) astNode()   {}
+
+// Visitor's Visit method is invoked by Walk for every node it
+// encounters. The boolean result says whether Walk should continue
+// into that node's children; returning false prunes the subtree.
+type Visitor interface {
+	Visit(node Node) bool
+}
+
+// visitorFunc adapts a plain func(Node) bool to the Visitor interface,
+// so callers that don't need visitor state can just pass a closure to
+// Walk instead of defining a type.
+type visitorFunc func(Node) bool
+
+func (f visitorFunc) Visit(node Node) bool { return f(node) }
+
+// Walk traverses an AST in depth-first order starting at node, calling
+// visit for node and every node reachable from it. If visit returns
+// false for a node, Walk doesn't descend into that node's children.
+// node may be a *Program, *Function, or any Expr/Stmt.
+func Walk(node Node, visit func(Node) bool) {
+	WalkVisitor(visitorFunc(visit), node)
+}
+
+// WalkVisitor is the Visitor-interface counterpart of Walk, for callers
+// that want to carry state across the traversal rather than close over
+// local variables.
+func WalkVisitor(v Visitor, node Node) {
+	if node == nil || !v.Visit(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *Program:
+		for i := range n.Functions {
+			WalkVisitor(v, &n.Functions[i])
+		}
+		walkStmts(v, n.Begin)
+		for _, action := range n.Actions {
+			for _, e := range action.Pattern {
+				WalkVisitor(v, e)
+			}
+			walkStmts(v, action.Stmts)
+		}
+		walkStmts(v, n.End)
+
+	case *Function:
+		walkStmts(v, n.Body)
+
+	case *BinaryExpr:
+		WalkVisitor(v, n.Left)
+		WalkVisitor(v, n.Right)
+	case *UnaryExpr:
+		WalkVisitor(v, n.Value)
+	case *IncrExpr:
+		WalkVisitor(v, n.Expr)
+	case *AssignExpr:
+		WalkVisitor(v, n.Left)
+		WalkVisitor(v, n.Right)
+	case *AugAssignExpr:
+		WalkVisitor(v, n.Left)
+		WalkVisitor(v, n.Right)
+	case *CondExpr:
+		WalkVisitor(v, n.Cond)
+		WalkVisitor(v, n.True)
+		WalkVisitor(v, n.False)
+	case *GroupingExpr:
+		for _, e := range n.Exprs {
+			WalkVisitor(v, e)
+		}
+	case *InExpr:
+		for _, e := range n.Index {
+			WalkVisitor(v, e)
+		}
+		WalkVisitor(v, n.Array)
+	case *IndexExpr:
+		WalkVisitor(v, n.Array)
+		for _, e := range n.Index {
+			WalkVisitor(v, e)
+		}
+	case *CallExpr:
+		for _, e := range n.Args {
+			WalkVisitor(v, e)
+		}
+	case *UserCallExpr:
+		for _, e := range n.Args {
+			WalkVisitor(v, e)
+		}
+	case *GetlineExpr:
+		WalkVisitor(v, n.Target)
+		WalkVisitor(v, n.Command)
+		WalkVisitor(v, n.File)
+	case *FieldExpr:
+		WalkVisitor(v, n.Index)
+	case *VarExpr, *ArrayExpr, *RegExpr, *NumExpr, *StrExpr:
+		// leaf expressions, nothing to recurse into
+
+	case *ExprStmt:
+		WalkVisitor(v, n.Expr)
+	case *PrintStmt:
+		for _, e := range n.Args {
+			WalkVisitor(v, e)
+		}
+	case *PrintfStmt:
+		for _, e := range n.Args {
+			WalkVisitor(v, e)
+		}
+	case *IfStmt:
+		WalkVisitor(v, n.Cond)
+		walkStmts(v, n.Body)
+		walkStmts(v, n.Else)
+	case *ForStmt:
+		WalkVisitor(v, n.Pre)
+		WalkVisitor(v, n.Cond)
+		WalkVisitor(v, n.Post)
+		walkStmts(v, n.Body)
+	case *ForInStmt:
+		WalkVisitor(v, n.Var)
+		WalkVisitor(v, n.Array)
+		walkStmts(v, n.Body)
+	case *WhileStmt:
+		WalkVisitor(v, n.Cond)
+		walkStmts(v, n.Body)
+	case *DoWhileStmt:
+		walkStmts(v, n.Body)
+		WalkVisitor(v, n.Cond)
+	case *BlockStmt:
+		walkStmts(v, n.Body)
+	case *ExitStmt:
+		WalkVisitor(v, n.Status)
+	case *DeleteStmt:
+		WalkVisitor(v, n.Array)
+		for _, e := range n.Index {
+			WalkVisitor(v, e)
+		}
+	case *ReturnStmt:
+		WalkVisitor(v, n.Value)
+	case *BreakStmt, *ContinueStmt, *NextStmt, *NextfileStmt:
+		// no children
+	}
+}
+
+// walkStmts walks a list of statements in order. Nil Exprs/Stmts, such
+// as an empty for-loop clause, are skipped by WalkVisitor's nil check.
+func walkStmts(v Visitor, stmts Stmts) {
+	for _, stmt := range stmts {
+		WalkVisitor(v, stmt)
+	}
+}
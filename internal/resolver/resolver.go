@@ -0,0 +1,538 @@
+// Package resolver resolves user function calls and variable types for
+// an already-parsed AST: it assigns VarExpr/ArrayExpr scopes and
+// indexes, fills in UserCallExpr.Index, and rejects programs that use
+// a name as both a scalar and an array. It runs entirely after
+// parsing, as a separate, independently testable pass -- the parser's
+// job ends at producing an untyped *ast.Program.
+package resolver
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	. "github.com/benhoyt/goawk/internal/ast"
+	. "github.com/benhoyt/goawk/lexer"
+)
+
+type varType int
+
+const (
+	typeUnknown varType = iota
+	typeScalar
+	typeArray
+)
+
+// typeInfo records type information for a single variable
+type typeInfo struct {
+	typ      varType
+	ref      *VarExpr
+	scope    VarScope
+	index    int
+	callName string
+	argIndex int
+	pos      Position // position of the reference that first pinned typ
+}
+
+// Used by printVarTypes when DebugTypes is turned on
+func (t typeInfo) String() string {
+	var typ string
+	switch t.typ {
+	case typeScalar:
+		typ = "Scalar"
+	case typeArray:
+		typ = "Array"
+	default:
+		typ = "Unknown"
+	}
+	var scope string
+	switch t.scope {
+	case ScopeGlobal:
+		scope = "Global"
+	case ScopeLocal:
+		scope = "Local"
+	default:
+		scope = "Special"
+	}
+	return fmt.Sprintf("typ=%s ref=%p scope=%s index=%d callName=%q argIndex=%d",
+		typ, t.ref, scope, t.index, t.callName, t.argIndex)
+}
+
+// A single variable reference (normally scalar)
+type varRef struct {
+	funcName string
+	ref      *VarExpr
+}
+
+// A single array reference
+type arrayRef struct {
+	funcName string
+	ref      *ArrayExpr
+}
+
+// Records a call to a user function (for resolving indexes later)
+type userCall struct {
+	call   *UserCallExpr
+	caller string // name of the enclosing function, or "" for top-level
+	pos    Position
+}
+
+// Resolver resolves variable types and user function calls over a
+// parsed *ast.Program. Use New to create one and Resolve to run it.
+type Resolver struct {
+	prog      *Program
+	varTypes  map[string]map[string]typeInfo
+	functions map[string]int
+	varRefs   []varRef
+	arrayRefs []arrayRef
+	userCalls []userCall
+	funcName  string
+	locals    map[string]bool
+
+	// DebugTypes, if set, makes Resolve print the resolved type of
+	// every variable to DebugWriter.
+	DebugTypes  bool
+	DebugWriter io.Writer
+}
+
+// New creates a Resolver for prog. Call Resolve to run it.
+func New(prog *Program) *Resolver {
+	r := &Resolver{prog: prog}
+	r.varTypes = make(map[string]map[string]typeInfo)
+	r.varTypes[""] = make(map[string]typeInfo) // globals
+	r.functions = make(map[string]int, len(prog.Functions))
+	for i, function := range prog.Functions {
+		r.functions[function.Name] = i
+	}
+	return r
+}
+
+// Resolve walks r's program, assigning variable scopes/indexes and
+// user call indexes, and returns a *ParseError if it finds a name used
+// as both a scalar and an array.
+func (r *Resolver) Resolve() (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			if parseErr, ok := e.(*ParseError); ok {
+				err = parseErr
+				return
+			}
+			panic(e)
+		}
+	}()
+
+	r.arrayRefByName("ARGV") // interpreter relies on ARGV being present
+	WalkVisitor(r, r.prog)
+	r.resolveUserCalls()
+	r.resolveVars()
+	return nil
+}
+
+// Visit implements ast.Visitor, discovering variable and call
+// references as it walks the program instead of piggybacking on
+// parse-time bookkeeping the way the old parser-embedded resolver did.
+func (r *Resolver) Visit(node Node) bool {
+	switch n := node.(type) {
+	case *Function:
+		r.startFunction(n.Name, n.Params, n.IsArray)
+		for _, stmt := range n.Body {
+			WalkVisitor(r, stmt)
+		}
+		r.stopFunction()
+		return false
+
+	case *UserCallExpr:
+		for i, arg := range n.Args {
+			// A bare-name argument might be passing an array by
+			// reference, so it isn't a scalar use by itself the way a
+			// bare name anywhere else is -- skip the immediate
+			// conflict check and let the call-argument type check in
+			// resolveVars catch a genuine mismatch once every type is
+			// settled. Anything more complex (e.g. f(x + 1)) does
+			// require x to be a scalar, so recurse normally.
+			if varExpr, ok := arg.(*VarExpr); ok {
+				r.callArgRef(varExpr)
+			} else {
+				WalkVisitor(r, arg)
+			}
+			r.processUserCallArg(n, arg, i)
+		}
+		r.userCalls = append(r.userCalls, userCall{n, r.funcName, n.Pos})
+		return false
+
+	case *VarExpr:
+		r.varRef(n)
+	case *ArrayExpr:
+		r.arrayRef(n)
+	}
+	return true
+}
+
+// Signal the start of a function: records the function name and local
+// variables so variable references can determine scope. isArray marks
+// which parameters were declared with the explicit "name[]" array
+// annotation (parallel to params, may be nil if none were); those are
+// pre-pinned to typeArray instead of typeUnknown, so the call-site
+// checker in resolveVars can reject a mismatched argument even if the
+// parameter is never referenced in the function body. Parameters
+// without the annotation get no entry here and are inferred from use.
+func (r *Resolver) startFunction(name string, params []string, isArray []bool) {
+	r.funcName = name
+	r.varTypes[name] = make(map[string]typeInfo)
+	r.locals = make(map[string]bool, len(params))
+	for i, param := range params {
+		r.locals[param] = true
+		if i < len(isArray) && isArray[i] {
+			r.varTypes[name][param] = typeInfo{typ: typeArray}
+		}
+	}
+}
+
+// Signal the end of a function
+func (r *Resolver) stopFunction() {
+	r.funcName = ""
+	r.locals = nil
+}
+
+// resolveUserCalls ensures every called function has actually been
+// defined, that it's not being called with too many arguments, and
+// fills in call.Index for the interpreter.
+func (r *Resolver) resolveUserCalls() {
+	for _, c := range r.userCalls {
+		index, ok := r.functions[c.call.Name]
+		if !ok {
+			panic(&ParseError{c.pos, fmt.Sprintf("undefined function %q", c.call.Name)})
+		}
+		function := r.prog.Functions[index]
+		if len(c.call.Args) > len(function.Params) {
+			panic(&ParseError{c.pos, fmt.Sprintf("%q called with more arguments than declared", c.call.Name)})
+		}
+		c.call.Index = index
+	}
+}
+
+// For arguments that are variable references, we don't know the
+// type based on context, so mark the types for these as unknown.
+func (r *Resolver) processUserCallArg(call *UserCallExpr, arg Expr, index int) {
+	if varExpr, ok := arg.(*VarExpr); ok {
+		ref := r.varTypes[r.funcName][varExpr.Name].ref
+		if ref == varExpr {
+			// Only applies if this is the first reference to this
+			// variable (otherwise we know the type already)
+			scope := r.varTypes[r.funcName][varExpr.Name].scope
+			r.varTypes[r.funcName][varExpr.Name] = typeInfo{typeUnknown, ref, scope, 0, call.Name, index, varExpr.Pos}
+		}
+	}
+}
+
+// Determine scope of given variable reference (and funcName if it's
+// a local, otherwise empty string)
+func (r *Resolver) getScope(name string) (VarScope, string) {
+	switch {
+	case r.funcName != "" && r.locals[name]:
+		return ScopeLocal, r.funcName
+	case SpecialVarIndex(name) > 0:
+		return ScopeSpecial, ""
+	default:
+		return ScopeGlobal, ""
+	}
+}
+
+// varRef records a scalar variable reference, setting expr's scope (but
+// not its Index, which is filled in later). It panics with a
+// *ParseError if name was already established as an array.
+//
+// expr.Pos is the position of this exact reference, set by the parser
+// when it built the node, so the error below points at the reference
+// that conflicts, not just the function or call site it's nested in.
+func (r *Resolver) varRef(expr *VarExpr) {
+	scope, funcName := r.getScope(expr.Name)
+	expr.Scope = scope
+	r.varRefs = append(r.varRefs, varRef{funcName, expr})
+	info := r.varTypes[funcName][expr.Name]
+	switch info.typ {
+	case typeArray:
+		panic(&ParseError{expr.Pos, fmt.Sprintf("can't use array %q as scalar", expr.Name)})
+	case typeUnknown:
+		r.varTypes[funcName][expr.Name] = typeInfo{typeScalar, expr, scope, 0, "", 0, expr.Pos}
+	}
+}
+
+// callArgRef records a bare-name call argument's reference, the same
+// way varRef does, but without its scalar/array conflict check: the
+// argument might be passing an already-established array by
+// reference, which the call-argument type check in resolveVars is
+// what's responsible for validating.
+//
+// This split exists because varRef's conflict check rejects the single
+// most common array usage in AWK if applied here unconditionally --
+// "x[1]=1; f(x)" -- since call arguments go through the same generic
+// reference-recording path as every other bare name.
+func (r *Resolver) callArgRef(expr *VarExpr) {
+	scope, funcName := r.getScope(expr.Name)
+	expr.Scope = scope
+	r.varRefs = append(r.varRefs, varRef{funcName, expr})
+	if r.varTypes[funcName][expr.Name].typ == typeUnknown {
+		r.varTypes[funcName][expr.Name] = typeInfo{typeScalar, expr, scope, 0, "", 0, expr.Pos}
+	}
+}
+
+// arrayRef records an array reference, setting expr's scope (but not
+// its Index, which is filled in later). It panics with a *ParseError
+// if name was already established as a scalar.
+func (r *Resolver) arrayRef(expr *ArrayExpr) {
+	scope, funcName := r.getScope(expr.Name)
+	expr.Scope = scope
+	r.arrayRefs = append(r.arrayRefs, arrayRef{funcName, expr})
+	info := r.varTypes[funcName][expr.Name]
+	switch info.typ {
+	case typeScalar:
+		panic(&ParseError{info.pos, fmt.Sprintf("can't use scalar %q as array", expr.Name)})
+	case typeUnknown:
+		r.varTypes[funcName][expr.Name] = typeInfo{typeArray, nil, scope, 0, "", 0, expr.Pos}
+	}
+}
+
+// arrayRef is also called directly (not via the walk) for the
+// synthetic ARGV reference the interpreter relies on.
+func (r *Resolver) arrayRefByName(name string) {
+	r.arrayRef(&ArrayExpr{Name: name})
+}
+
+// Print variable type information (for debugging) on r.DebugWriter
+func (r *Resolver) printVarTypes() {
+	funcNames := []string{}
+	for funcName := range r.varTypes {
+		funcNames = append(funcNames, funcName)
+	}
+	sort.Strings(funcNames)
+	for _, funcName := range funcNames {
+		if funcName != "" {
+			fmt.Fprintf(r.DebugWriter, "function %s\n", funcName)
+		} else {
+			fmt.Fprintf(r.DebugWriter, "globals\n")
+		}
+		varNames := []string{}
+		for name := range r.varTypes[funcName] {
+			varNames = append(varNames, name)
+		}
+		sort.Strings(varNames)
+		for _, name := range varNames {
+			info := r.varTypes[funcName][name]
+			fmt.Fprintf(r.DebugWriter, "  %s: %s\n", name, info)
+		}
+	}
+}
+
+// If we can't finish resolving a strongly-connected component of
+// mutually-recursive functions after this many iterations, give up
+const maxResolveIterations = 10000
+
+// resolveVars resolves unknown variable types and generates variable
+// indexes and name-to-index mappings for the interpreter.
+func (r *Resolver) resolveVars() {
+	// Determine the type of each unknown variable from the parameter
+	// type in the function it's passed to. processUserCallArg only
+	// leaves a variable's type unknown when it's passed as an argument
+	// to another user function, so resolving functions in reverse
+	// topological order of the call graph (callees before callers)
+	// lets each function be finalized in a single pass: by the time we
+	// look at a caller, every function it calls has already settled
+	// its parameter types. Mutually-recursive functions can't be
+	// ordered this way, so each strongly-connected component falls
+	// back to a fixed-point loop, scoped to just that component.
+	nodes := make([]string, 0, len(r.varTypes))
+	for funcName := range r.varTypes {
+		nodes = append(nodes, funcName)
+	}
+	edges := make(map[string][]string)
+	for _, c := range r.userCalls {
+		edges[c.caller] = append(edges[c.caller], c.call.Name)
+	}
+	for _, component := range toposort(nodes, edges) {
+		if len(component) == 1 && !hasSelfEdge(edges, component[0]) {
+			r.resolveFuncVarTypes(component[0])
+			continue
+		}
+		r.resolveVarTypesFixedPoint(component)
+	}
+
+	// Now that every variable's type is settled, check that each call
+	// argument's type actually matches the corresponding parameter's
+	// type: this is what catches a name being used as both a scalar
+	// and an array.
+	for _, c := range r.userCalls {
+		function := r.prog.Functions[r.functions[c.call.Name]]
+		for i, arg := range c.call.Args {
+			if i >= len(function.Params) {
+				continue // already reported by resolveUserCalls
+			}
+			paramType := r.varTypes[c.call.Name][function.Params[i]].typ
+			argType, name := r.argVarType(c.caller, arg)
+			if argType == typeUnknown || paramType == typeUnknown || argType == paramType {
+				continue
+			}
+			if argType == typeArray {
+				panic(&ParseError{argPos(arg), fmt.Sprintf("can't pass array %q as scalar parameter", name)})
+			}
+			panic(&ParseError{argPos(arg), fmt.Sprintf("can't pass scalar %q as array parameter", name)})
+		}
+	}
+
+	// Resolve global variables (iteration order is undefined, so
+	// assign indexes basically randomly)
+	r.prog.Scalars = make(map[string]int)
+	r.prog.Arrays = make(map[string]int)
+	for name, info := range r.varTypes[""] {
+		var index int
+		if info.scope == ScopeSpecial {
+			index = SpecialVarIndex(name)
+		} else if info.typ == typeScalar {
+			index = len(r.prog.Scalars)
+			r.prog.Scalars[name] = index
+		} else {
+			index = len(r.prog.Arrays)
+			r.prog.Arrays[name] = index
+		}
+		info.index = index
+		r.varTypes[""][name] = info
+	}
+
+	// Resolve local variables (assign indexes in order of params).
+	// Also patch up Function.Arrays (tells interpreter which args
+	// are arrays).
+	for funcName, infos := range r.varTypes {
+		if funcName == "" {
+			continue
+		}
+		scalarIndex := 0
+		arrayIndex := 0
+		functionIndex := r.functions[funcName]
+		function := r.prog.Functions[functionIndex]
+		arrays := make([]bool, len(function.Params))
+		for i, name := range function.Params {
+			info := infos[name]
+			var index int
+			if info.typ == typeArray {
+				index = arrayIndex
+				arrayIndex++
+				arrays[i] = true
+			} else {
+				// typeScalar or typeUnknown: variables may still be
+				// of unknown type if they've never been referenced --
+				// default to scalar in that case
+				index = scalarIndex
+				scalarIndex++
+			}
+			info.index = index
+			r.varTypes[funcName][name] = info
+		}
+		r.prog.Functions[functionIndex].Arrays = arrays
+	}
+
+	if r.DebugTypes && r.DebugWriter != nil {
+		r.printVarTypes()
+	}
+
+	// Patch up variable indexes (interpreter uses an index instead
+	// the name for more efficient lookups)
+	for _, ref := range r.varRefs {
+		info := r.varTypes[ref.funcName][ref.ref.Name]
+		ref.ref.Index = info.index
+	}
+	for _, ref := range r.arrayRefs {
+		info := r.varTypes[ref.funcName][ref.ref.Name]
+		ref.ref.Index = info.index
+	}
+}
+
+// argVarType returns the resolved type of a user call argument as
+// recorded in funcName's own variables, along with its name. Globals
+// are not looked up: a global passed from inside a function is
+// reported as typeUnknown and so isn't checked. Arguments that aren't a
+// plain variable or array reference (literals, expressions, etc.) can
+// only ever be scalar, so they're reported as typeUnknown to skip the
+// array/scalar mismatch check above.
+func (r *Resolver) argVarType(funcName string, arg Expr) (varType, string) {
+	switch e := arg.(type) {
+	case *VarExpr:
+		return r.varTypes[funcName][e.Name].typ, e.Name
+	case *ArrayExpr:
+		return r.varTypes[funcName][e.Name].typ, e.Name
+	default:
+		return typeUnknown, ""
+	}
+}
+
+// argPos returns the position of a user call argument, for pointing a
+// scalar/array mismatch error at the argument itself rather than the
+// call as a whole. Only *VarExpr and *ArrayExpr ever reach this check
+// (see argVarType), so those are the only cases that matter.
+func argPos(arg Expr) Position {
+	switch e := arg.(type) {
+	case *VarExpr:
+		return e.Pos
+	case *ArrayExpr:
+		return e.Pos
+	default:
+		return Position{}
+	}
+}
+
+// hasSelfEdge reports whether funcName calls itself directly, in which
+// case it needs the fixed-point treatment even though it's alone in its
+// strongly-connected component.
+func hasSelfEdge(edges map[string][]string, funcName string) bool {
+	for _, callee := range edges[funcName] {
+		if callee == funcName {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveFuncVarTypes resolves the unknown variable types of a single
+// function in one pass, assuming every function it calls has already
+// been resolved.
+func (r *Resolver) resolveFuncVarTypes(funcName string) {
+	for name, info := range r.varTypes[funcName] {
+		if info.typ != typeUnknown {
+			continue
+		}
+		paramName := r.prog.Functions[r.functions[info.callName]].Params[info.argIndex]
+		typ := r.varTypes[info.callName][paramName].typ
+		if typ != typeUnknown {
+			info.typ = typ
+			r.varTypes[funcName][name] = info
+		}
+	}
+}
+
+// resolveVarTypesFixedPoint resolves the unknown variable types of a
+// strongly-connected component of mutually-recursive functions by
+// looping until nothing changes -- the way the whole program used to
+// be resolved before it could be ordered by toposort.
+func (r *Resolver) resolveVarTypesFixedPoint(component []string) {
+	for i := 0; ; i++ {
+		progressed := false
+		for _, funcName := range component {
+			for name, info := range r.varTypes[funcName] {
+				if info.typ == typeUnknown {
+					paramName := r.prog.Functions[r.functions[info.callName]].Params[info.argIndex]
+					typ := r.varTypes[info.callName][paramName].typ
+					if typ != typeUnknown {
+						info.typ = typ
+						r.varTypes[funcName][name] = info
+						progressed = true
+					}
+				}
+			}
+		}
+		if !progressed {
+			break
+		}
+		if i >= maxResolveIterations {
+			panic(&ParseError{Position{}, "too many iterations trying to resolve variable types"})
+		}
+	}
+}
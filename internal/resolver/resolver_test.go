@@ -0,0 +1,231 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/benhoyt/goawk/internal/ast"
+	. "github.com/benhoyt/goawk/lexer"
+)
+
+func resolveErr(t *testing.T, prog *Program) string {
+	t.Helper()
+	err := New(prog).Resolve()
+	if err == nil {
+		t.Fatalf("expected a resolve error, got none")
+	}
+	return err.Error()
+}
+
+func TestResolveArrayThenScalar(t *testing.T) {
+	prog := &Program{
+		Actions: []Action{{Stmts: Stmts{
+			&PrintStmt{Args: []Expr{&IndexExpr{Array: &ArrayExpr{Name: "x"}, Index: []Expr{&NumExpr{Value: 1}}}}},
+			&PrintStmt{Args: []Expr{&VarExpr{Name: "x"}}},
+		}}},
+	}
+	if err := resolveErr(t, prog); !strings.Contains(err, "can't use array") {
+		t.Errorf("expected array/scalar error, got %q", err)
+	}
+}
+
+func TestResolveScalarThenArray(t *testing.T) {
+	prog := &Program{
+		Actions: []Action{{Stmts: Stmts{
+			&PrintStmt{Args: []Expr{&VarExpr{Name: "x"}}},
+			&PrintStmt{Args: []Expr{&IndexExpr{Array: &ArrayExpr{Name: "x"}, Index: []Expr{&NumExpr{Value: 1}}}}},
+		}}},
+	}
+	if err := resolveErr(t, prog); !strings.Contains(err, "can't use scalar") {
+		t.Errorf("expected scalar/array error, got %q", err)
+	}
+}
+
+func TestResolveErrorReportsReferencePosition(t *testing.T) {
+	// The walk-based resolver discovers references by walking the
+	// finished AST rather than at parse time, so it must read each
+	// node's own Pos instead of falling back to a zero Position.
+	scalarUse := &VarExpr{Name: "x", Pos: Position{Line: 2, Column: 7}}
+	prog := &Program{
+		Actions: []Action{{Stmts: Stmts{
+			&PrintStmt{Args: []Expr{&IndexExpr{Array: &ArrayExpr{Name: "x"}, Index: []Expr{&NumExpr{Value: 1}}}}},
+			&PrintStmt{Args: []Expr{scalarUse}},
+		}}},
+	}
+	err := New(prog).Resolve()
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected *ParseError, got %T (%v)", err, err)
+	}
+	if parseErr.Position != scalarUse.Pos {
+		t.Errorf("expected error position %v (the conflicting reference), got %v", scalarUse.Pos, parseErr.Position)
+	}
+}
+
+func TestResolveCallArgOneHop(t *testing.T) {
+	// f treats its parameter as an array, but the caller passes a
+	// variable it already uses as a scalar.
+	prog := &Program{
+		Functions: []Function{{
+			Name:   "f",
+			Params: []string{"a"},
+			Body: Stmts{
+				&ExprStmt{Expr: &IndexExpr{Array: &ArrayExpr{Name: "a"}, Index: []Expr{&NumExpr{Value: 1}}}},
+			},
+		}},
+		Actions: []Action{{Stmts: Stmts{
+			&PrintStmt{Args: []Expr{&VarExpr{Name: "x"}}},
+			&ExprStmt{Expr: &UserCallExpr{Name: "f", Args: []Expr{&VarExpr{Name: "x"}}}},
+		}}},
+	}
+	if err := resolveErr(t, prog); !strings.Contains(err, "array") {
+		t.Errorf("expected array/scalar error, got %q", err)
+	}
+}
+
+func TestResolveArrayPassedByReferenceRegression(t *testing.T) {
+	// Regression test for the scalar/array ParseErrors added in
+	// chunk0-2: varRef's unconditional panic-on-conflict treated a bare
+	// array name passed as a call argument the same as any other bare
+	// reference, which rejected the single most common array usage in
+	// AWK -- x[1]=1; f(x) -- because call arguments go through varRef
+	// too. callArgRef exists specifically to carve this case out: x is
+	// already known to be an array from direct use in the top-level
+	// action, then passed by reference to a function that also treats
+	// its parameter as an array, and that must not be flagged -- only
+	// genuine scalar/array misuse should be.
+	prog := &Program{
+		Functions: []Function{{
+			Name:   "f",
+			Params: []string{"a"},
+			Body: Stmts{
+				&ExprStmt{Expr: &IndexExpr{Array: &ArrayExpr{Name: "a"}, Index: []Expr{&NumExpr{Value: 1}}}},
+			},
+		}},
+		Actions: []Action{{Stmts: Stmts{
+			&ExprStmt{Expr: &IndexExpr{Array: &ArrayExpr{Name: "x"}, Index: []Expr{&NumExpr{Value: 1}}}},
+			&ExprStmt{Expr: &UserCallExpr{Name: "f", Args: []Expr{&VarExpr{Name: "x"}}}},
+		}}},
+	}
+	if err := New(prog).Resolve(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestResolveCallArgTwoHops(t *testing.T) {
+	// The array-ness of b only becomes apparent two calls deep, but
+	// it must still be caught at the g(x) call site.
+	prog := &Program{
+		Functions: []Function{
+			{
+				Name:   "f",
+				Params: []string{"b"},
+				Body: Stmts{
+					&ExprStmt{Expr: &IndexExpr{Array: &ArrayExpr{Name: "b"}, Index: []Expr{&NumExpr{Value: 1}}}},
+				},
+			},
+			{
+				Name:   "g",
+				Params: []string{"a"},
+				Body: Stmts{
+					&ExprStmt{Expr: &UserCallExpr{Name: "f", Args: []Expr{&VarExpr{Name: "a"}}}},
+				},
+			},
+		},
+		Actions: []Action{{Stmts: Stmts{
+			&PrintStmt{Args: []Expr{&VarExpr{Name: "x"}}},
+			&ExprStmt{Expr: &UserCallExpr{Name: "g", Args: []Expr{&VarExpr{Name: "x"}}}},
+		}}},
+	}
+	if err := resolveErr(t, prog); !strings.Contains(err, "array") {
+		t.Errorf("expected array/scalar error, got %q", err)
+	}
+}
+
+func TestResolveAnnotatedArrayParamConflict(t *testing.T) {
+	// f's parameter is explicitly annotated as an array ("function
+	// f(a[])"); its body never references a, so only the annotation
+	// lets us catch that the caller passes a known scalar.
+	prog := &Program{
+		Functions: []Function{{
+			Name:    "f",
+			Params:  []string{"a"},
+			IsArray: []bool{true},
+		}},
+		Actions: []Action{{Stmts: Stmts{
+			&PrintStmt{Args: []Expr{&VarExpr{Name: "x"}}},
+			&ExprStmt{Expr: &UserCallExpr{Name: "f", Args: []Expr{&VarExpr{Name: "x"}}}},
+		}}},
+	}
+	if err := resolveErr(t, prog); !strings.Contains(err, "array") {
+		t.Errorf("expected array/scalar error, got %q", err)
+	}
+}
+
+func TestResolveAnnotatedArrayParamOK(t *testing.T) {
+	// x is referenced for the first time as the call argument itself,
+	// so its type comes entirely from f's annotation, with nothing to
+	// conflict with.
+	prog := &Program{
+		Functions: []Function{{
+			Name:    "f",
+			Params:  []string{"a"},
+			IsArray: []bool{true},
+		}},
+		Actions: []Action{{Stmts: Stmts{
+			&ExprStmt{Expr: &UserCallExpr{Name: "f", Args: []Expr{&VarExpr{Name: "x"}}}},
+		}}},
+	}
+	if err := New(prog).Resolve(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestResolveRecursiveFunctionOK(t *testing.T) {
+	// Self-recursion exercises the fixed-point fallback for the
+	// self-loop strongly-connected component, and must resolve
+	// cleanly when usage is consistent.
+	prog := &Program{
+		Functions: []Function{{
+			Name:   "fact",
+			Params: []string{"n"},
+			Body: Stmts{
+				&ExprStmt{Expr: &UserCallExpr{Name: "fact", Args: []Expr{&VarExpr{Name: "n"}}}},
+			},
+		}},
+		Actions: []Action{{Stmts: Stmts{
+			&ExprStmt{Expr: &UserCallExpr{Name: "fact", Args: []Expr{&NumExpr{Value: 5}}}},
+		}}},
+	}
+	if err := New(prog).Resolve(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestToposortLeavesFirst(t *testing.T) {
+	nodes := []string{"", "caller", "callee"}
+	edges := map[string][]string{
+		"":       {"caller"},
+		"caller": {"callee"},
+	}
+	components := toposort(nodes, edges)
+	pos := make(map[string]int)
+	for i, c := range components {
+		pos[c[0]] = i
+	}
+	if !(pos["callee"] < pos["caller"] && pos["caller"] < pos[""]) {
+		t.Errorf("expected callee before caller before top-level, got order %v", components)
+	}
+}
+
+func TestToposortCycle(t *testing.T) {
+	nodes := []string{"isEven", "isOdd"}
+	edges := map[string][]string{
+		"isEven": {"isOdd"},
+		"isOdd":  {"isEven"},
+	}
+	components := toposort(nodes, edges)
+	if len(components) != 1 || len(components[0]) != 2 {
+		t.Errorf("expected isEven and isOdd in a single SCC, got %v", components)
+	}
+}
@@ -0,0 +1,87 @@
+// Topological sort of the user-function call graph, used by resolveVars
+// to order type resolution so each function can (usually) be finalized
+// in a single pass instead of looping to a fixed point.
+
+package resolver
+
+import "sort"
+
+// toposort returns the strongly connected components of the call graph
+// described by edges (caller -> callees), in an order such that a
+// function's callees always appear in an earlier or the same component
+// as the function itself ("leaves first"). Components with more than
+// one function indicate mutual recursion; a component containing a
+// function that calls itself directly is also reported on its own
+// (self-loop), since it needs the same fixed-point treatment.
+//
+// Within each component, function names are sorted so the result is
+// deterministic regardless of map iteration order.
+func toposort(nodes []string, edges map[string][]string) [][]string {
+	t := &tarjan{
+		edges: edges,
+		index: make(map[string]int),
+		low:   make(map[string]int),
+		onStk: make(map[string]bool),
+	}
+	sorted := make([]string, len(nodes))
+	copy(sorted, nodes)
+	sort.Strings(sorted)
+	for _, n := range sorted {
+		if _, ok := t.index[n]; !ok {
+			t.strongConnect(n)
+		}
+	}
+	return t.components
+}
+
+// tarjan holds the state for Tarjan's strongly-connected-components
+// algorithm, run over the call graph.
+type tarjan struct {
+	edges      map[string][]string
+	index      map[string]int
+	low        map[string]int
+	onStk      map[string]bool
+	stack      []string
+	next       int
+	components [][]string
+}
+
+func (t *tarjan) strongConnect(v string) {
+	t.index[v] = t.next
+	t.low[v] = t.next
+	t.next++
+	t.stack = append(t.stack, v)
+	t.onStk[v] = true
+
+	callees := make([]string, len(t.edges[v]))
+	copy(callees, t.edges[v])
+	sort.Strings(callees)
+	for _, w := range callees {
+		if _, ok := t.index[w]; !ok {
+			t.strongConnect(w)
+			if t.low[w] < t.low[v] {
+				t.low[v] = t.low[w]
+			}
+		} else if t.onStk[w] {
+			if t.index[w] < t.low[v] {
+				t.low[v] = t.index[w]
+			}
+		}
+	}
+
+	if t.low[v] == t.index[v] {
+		var component []string
+		for {
+			n := len(t.stack) - 1
+			w := t.stack[n]
+			t.stack = t.stack[:n]
+			t.onStk[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		sort.Strings(component)
+		t.components = append(t.components, component)
+	}
+}
@@ -0,0 +1,205 @@
+// Package lexer tokenizes AWK source.
+//
+// This is a minimal lexer: it covers the names, numbers, strings, and
+// punctuation that internal/resolver and the parser package currently
+// need to build VarExpr/ArrayExpr/UserCallExpr nodes and parse
+// function declarations, not the full AWK grammar.
+package lexer
+
+import (
+	"fmt"
+)
+
+// Position is a 1-based line/column location in the source.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// ParseError is raised (as a panic, recovered at the top of parsing or
+// resolution) for a syntax error or a resolution failure such as using
+// a name as both a scalar and an array.
+type ParseError struct {
+	Position Position
+	Message  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse error at %s: %s", e.Position, e.Message)
+}
+
+// Token identifies the kind of a lexical token.
+type Token int
+
+const (
+	ILLEGAL Token = iota
+	EOF
+	NEWLINE
+
+	NAME
+	NUMBER
+	STRING
+
+	FUNCTION
+
+	LPAREN
+	RPAREN
+	LBRACE
+	RBRACE
+	LBRACKET
+	RBRACKET
+	COMMA
+	SEMICOLON
+)
+
+var tokenNames = map[Token]string{
+	ILLEGAL:   "illegal token",
+	EOF:       "end of input",
+	NEWLINE:   "newline",
+	NAME:      "name",
+	NUMBER:    "number",
+	STRING:    "string",
+	FUNCTION:  "function",
+	LPAREN:    "(",
+	RPAREN:    ")",
+	LBRACE:    "{",
+	RBRACE:    "}",
+	LBRACKET:  "[",
+	RBRACKET:  "]",
+	COMMA:     ",",
+	SEMICOLON: ";",
+}
+
+func (t Token) String() string {
+	if name, ok := tokenNames[t]; ok {
+		return name
+	}
+	return "unknown token"
+}
+
+var keywords = map[string]Token{
+	"function": FUNCTION,
+	"func":     FUNCTION,
+}
+
+// Lexer tokenizes src one token at a time via Scan.
+type Lexer struct {
+	src    []byte
+	offset int
+	line   int
+	column int
+}
+
+// NewLexer creates a Lexer over src.
+func NewLexer(src []byte) *Lexer {
+	return &Lexer{src: src, line: 1, column: 1}
+}
+
+// Scan returns the next token, its text, and the position it started
+// at. Once the source is exhausted it returns EOF repeatedly.
+func (l *Lexer) Scan() (Position, Token, string) {
+	l.skipSpace()
+	pos := Position{l.line, l.column}
+	if l.offset >= len(l.src) {
+		return pos, EOF, ""
+	}
+
+	c := l.src[l.offset]
+	switch {
+	case c == '\n':
+		l.advance(1)
+		return pos, NEWLINE, "\n"
+	case c == '(':
+		l.advance(1)
+		return pos, LPAREN, "("
+	case c == ')':
+		l.advance(1)
+		return pos, RPAREN, ")"
+	case c == '{':
+		l.advance(1)
+		return pos, LBRACE, "{"
+	case c == '}':
+		l.advance(1)
+		return pos, RBRACE, "}"
+	case c == '[':
+		l.advance(1)
+		return pos, LBRACKET, "["
+	case c == ']':
+		l.advance(1)
+		return pos, RBRACKET, "]"
+	case c == ',':
+		l.advance(1)
+		return pos, COMMA, ","
+	case c == ';':
+		l.advance(1)
+		return pos, SEMICOLON, ";"
+	case isNameStart(c):
+		start := l.offset
+		for l.offset < len(l.src) && isNameCont(l.src[l.offset]) {
+			l.advance(1)
+		}
+		text := string(l.src[start:l.offset])
+		if tok, ok := keywords[text]; ok {
+			return pos, tok, text
+		}
+		return pos, NAME, text
+	case isDigit(c):
+		start := l.offset
+		for l.offset < len(l.src) && (isDigit(l.src[l.offset]) || l.src[l.offset] == '.') {
+			l.advance(1)
+		}
+		return pos, NUMBER, string(l.src[start:l.offset])
+	case c == '"':
+		start := l.offset
+		l.advance(1)
+		for l.offset < len(l.src) && l.src[l.offset] != '"' {
+			l.advance(1)
+		}
+		if l.offset < len(l.src) {
+			l.advance(1) // closing quote
+		}
+		return pos, STRING, string(l.src[start:l.offset])
+	default:
+		l.advance(1)
+		return pos, ILLEGAL, string(c)
+	}
+}
+
+func (l *Lexer) skipSpace() {
+	for l.offset < len(l.src) {
+		switch l.src[l.offset] {
+		case ' ', '\t', '\r':
+			l.advance(1)
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) advance(n int) {
+	for i := 0; i < n; i++ {
+		if l.src[l.offset] == '\n' {
+			l.line++
+			l.column = 1
+		} else {
+			l.column++
+		}
+		l.offset++
+	}
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameCont(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,63 @@
+// Parsing of function declarations, including the optional "name[]"
+// array-parameter annotation consumed by internal/resolver.
+
+package parser
+
+import (
+	. "github.com/benhoyt/goawk/internal/ast"
+	. "github.com/benhoyt/goawk/lexer"
+)
+
+// function parses a function declaration:
+//
+//	"function" NAME "(" params ")" "{" body "}"
+func (p *parser) function() *Function {
+	p.expect(FUNCTION)
+	name := p.val
+	p.expect(NAME)
+	params, isArray := p.functionParams()
+	body := p.stmtsBlock()
+	return &Function{Name: name, Params: params, IsArray: isArray, Body: body}
+}
+
+// functionParams parses the parenthesized parameter list of a function
+// declaration, e.g. "(a, b[], c)", and returns the parameter names
+// alongside a parallel isArray slice marking which ones were annotated
+// with a trailing "[]" to declare them as array parameters.
+//
+// The "[]" is just the two existing LBRACKET/RBRACKET tokens the lexer
+// already produces for array indexing -- nothing new to tokenize --
+// but a NAME immediately followed by an empty "[]" only ever makes
+// sense here, right after a parameter name, so it's parsed as part of
+// the parameter list grammar rather than as an index expression.
+//
+// isArray is nil if no parameter in the list was annotated, so a
+// program with no annotations at all produces the same nil slice it
+// always did, leaving the resolver's existing inference path for that
+// case untouched.
+func (p *parser) functionParams() (params []string, isArray []bool) {
+	p.expect(LPAREN)
+	for p.tok != RPAREN {
+		if len(params) > 0 {
+			p.expect(COMMA)
+		}
+		name := p.val
+		p.expect(NAME)
+		params = append(params, name)
+
+		array := false
+		if p.tok == LBRACKET {
+			p.next()
+			p.expect(RBRACKET)
+			array = true
+		}
+		if array && isArray == nil {
+			isArray = make([]bool, len(params)-1, len(params))
+		}
+		if isArray != nil {
+			isArray = append(isArray, array)
+		}
+	}
+	p.expect(RPAREN)
+	return params, isArray
+}
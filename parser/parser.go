@@ -0,0 +1,189 @@
+// Package parser implements a parser for (a subset of) the AWK language.
+package parser
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	. "github.com/benhoyt/goawk/internal/ast"
+	"github.com/benhoyt/goawk/internal/resolver"
+	. "github.com/benhoyt/goawk/lexer"
+)
+
+// ParserConfig controls the behavior of ParseProgram.
+type ParserConfig struct {
+	// DebugTypes, if set, makes the resolver print the resolved type of
+	// every variable to DebugWriter as it runs -- see internal/resolver.
+	DebugTypes  bool
+	DebugWriter io.Writer
+}
+
+// parser holds the state for a single parse: the lexer it's pulling
+// tokens from and the token currently being looked at.
+type parser struct {
+	lexer *Lexer
+	tok   Token
+	val   string
+	pos   Position
+}
+
+func newParser(config *ParserConfig) *parser {
+	return &parser{}
+}
+
+// next advances to the next token.
+func (p *parser) next() {
+	p.pos, p.tok, p.val = p.lexer.Scan()
+}
+
+// expect consumes the current token if it matches tok, or panics with
+// a *ParseError pointing at the unexpected token.
+func (p *parser) expect(tok Token) {
+	if p.tok != tok {
+		panic(&ParseError{p.pos, fmt.Sprintf("expected %s, got %s", tok, p.tok)})
+	}
+	p.next()
+}
+
+// skipNewlines consumes any run of newlines and statement separators
+// between rules -- AWK treats blank lines between pattern-action rules
+// as insignificant.
+func (p *parser) skipNewlines() {
+	for p.tok == NEWLINE || p.tok == SEMICOLON {
+		p.next()
+	}
+}
+
+// program parses a whole AWK program: a sequence of function
+// declarations and pattern-action rules.
+func (p *parser) program(src []byte) *Program {
+	p.lexer = NewLexer(src)
+	p.next()
+
+	prog := &Program{}
+	p.skipNewlines()
+	for p.tok != EOF {
+		if p.tok == FUNCTION {
+			prog.Functions = append(prog.Functions, *p.function())
+		} else {
+			prog.Actions = append(prog.Actions, p.action())
+		}
+		p.skipNewlines()
+	}
+	return prog
+}
+
+// action parses a single pattern-action rule. This minimal grammar
+// only supports unconditional "{ ... }" rules -- the BEGIN/END and
+// expression patterns real AWK allows aren't needed by anything that
+// consumes this package yet.
+func (p *parser) action() Action {
+	return Action{Stmts: p.stmtsBlock()}
+}
+
+// stmtsBlock parses a brace-delimited statement list.
+func (p *parser) stmtsBlock() Stmts {
+	p.expect(LBRACE)
+	var stmts Stmts
+	p.skipNewlines()
+	for p.tok != RBRACE && p.tok != EOF {
+		stmts = append(stmts, p.stmt())
+		p.skipNewlines()
+	}
+	p.expect(RBRACE)
+	return stmts
+}
+
+// stmt parses a single statement. Only expression statements are
+// supported so far -- print/if/for/while and the rest of ast.Stmt are
+// declared for the resolver and interpreter to consume, but nothing
+// needs this parser to produce them yet.
+func (p *parser) stmt() Stmt {
+	expr := p.expr()
+	return &ExprStmt{Expr: expr}
+}
+
+// expr parses an expression. This minimal grammar covers exactly what
+// the resolver needs to discover: a bare name (VarExpr), a call
+// "name(args)" (UserCallExpr), and an index "name[index]" (IndexExpr
+// over an ArrayExpr) -- the operators and other literals the rest of
+// ast.Expr supports aren't parsed here yet.
+func (p *parser) expr() Expr {
+	pos := p.pos
+	switch p.tok {
+	case NUMBER:
+		text := p.val
+		p.next()
+		value, _ := strconv.ParseFloat(text, 64)
+		return &NumExpr{Value: value}
+	case STRING:
+		text := p.val
+		p.next()
+		return &StrExpr{Value: text}
+	case NAME:
+		name := p.val
+		p.next()
+		switch p.tok {
+		case LPAREN:
+			p.next()
+			var args []Expr
+			for p.tok != RPAREN {
+				if len(args) > 0 {
+					p.expect(COMMA)
+				}
+				args = append(args, p.expr())
+			}
+			p.expect(RPAREN)
+			return &UserCallExpr{Name: name, Args: args, Pos: pos}
+		case LBRACKET:
+			p.next()
+			var index []Expr
+			for p.tok != RBRACKET {
+				if len(index) > 0 {
+					p.expect(COMMA)
+				}
+				index = append(index, p.expr())
+			}
+			p.expect(RBRACKET)
+			return &IndexExpr{Array: &ArrayExpr{Name: name, Pos: pos}, Index: index}
+		default:
+			return &VarExpr{Name: name, Pos: pos}
+		}
+	default:
+		panic(&ParseError{pos, fmt.Sprintf("unexpected %s", p.tok)})
+	}
+}
+
+// ParseProgram parses an AWK program and resolves all variable types
+// and user function calls, returning a *Program ready for the
+// interpreter or compiler to consume directly.
+//
+// Resolution used to happen inline here, via resolveUserCalls and
+// resolveVars methods invoked at the end of parsing. That logic now
+// lives in internal/resolver as an independent, separately-testable
+// pass -- parsing's job ends at producing the untyped AST below.
+func ParseProgram(src []byte, config *ParserConfig) (prog *Program, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if parseErr, ok := r.(*ParseError); ok {
+				err = parseErr
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	p := newParser(config)
+	prog = p.program(src)
+
+	res := resolver.New(prog)
+	if config != nil {
+		res.DebugTypes = config.DebugTypes
+		res.DebugWriter = config.DebugWriter
+	}
+	if resolveErr := res.Resolve(); resolveErr != nil {
+		return nil, resolveErr
+	}
+	return prog, nil
+}